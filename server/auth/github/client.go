@@ -0,0 +1,157 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+)
+
+// errTwoFactor and errUnauthorized are returned by Client.CreateAuthorization
+// to signal, respectively, that the account requires an OTP that wasn't
+// supplied, or that the username/password/otp were rejected. Authenticator
+// translates both into the matching auth package error.
+var (
+	errTwoFactor    = errors.New("github: two factor authentication required")
+	errUnauthorized = errors.New("github: invalid username, password or otp")
+)
+
+// CreateAuthorizationOptions are the credentials used to create an OAuth
+// authorization via GitHub's Non-Web Application Flow.
+type CreateAuthorizationOptions struct {
+	Username string
+	Password string
+	OTP      string
+}
+
+// Authorization is the result of a successful CreateAuthorization call.
+type Authorization struct {
+	Token string
+}
+
+// User is the subset of a GitHub user that Empire cares about.
+type User struct {
+	Login string
+}
+
+// Client is a thin wrapper around the GitHub v3 API that backs
+// Authenticator, OAuth2Authenticator, GitHubAppTokenSource,
+// OrganizationAuthorizer and TeamAuthorizer. Most of its methods take a
+// per-call token rather than authenticating once at construction time,
+// since a single Client is shared across requests made on behalf of many
+// different users (and, for CreateAuthorization, no token exists yet).
+type Client struct {
+	httpClient *http.Client
+	github     *github.Client
+}
+
+// NewClient returns a new Client that issues unauthenticated requests (or
+// basic-auth requests, for CreateAuthorization) using c. c is typically
+// http.DefaultClient; per-user authentication is applied per call. c's
+// transport (e.g. a test server's, or one configured for GitHub Enterprise)
+// is reused by withToken, so it applies to every call, not just this one.
+func NewClient(c *http.Client) *Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &Client{httpClient: c, github: github.NewClient(c)}
+}
+
+// CreateAuthorization creates a new OAuth authorization for the user
+// identified by opts, via the (deprecated) Non-Web Application Flow.
+//
+// https://developer.github.com/v3/oauth_authorizations/#create-a-new-authorization-if-needed
+func (c *Client) CreateAuthorization(ctx context.Context, opts CreateAuthorizationOptions) (*Authorization, error) {
+	req, err := c.github.NewRequest("POST", "authorizations", &github.AuthorizationRequest{
+		Scopes: []github.Scope{github.ScopeRepo},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(opts.Username, opts.Password)
+	if opts.OTP != "" {
+		req.Header.Set("X-GitHub-OTP", opts.OTP)
+	}
+
+	var authorization github.Authorization
+	resp, err := c.github.Do(ctx, req, &authorization)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			if resp.Header.Get("X-GitHub-OTP") != "" {
+				return nil, errTwoFactor
+			}
+			return nil, errUnauthorized
+		}
+		return nil, err
+	}
+
+	return &Authorization{Token: authorization.GetToken()}, nil
+}
+
+// GetUser resolves the GitHub user that token authenticates as.
+func (c *Client) GetUser(ctx context.Context, token string) (*User, error) {
+	u, _, err := c.withToken(ctx, token).Users.Get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return &User{Login: u.GetLogin()}, nil
+}
+
+// IsOrganizationMember reports whether the user authenticated by token is a
+// member of organization.
+//
+// https://developer.github.com/v3/orgs/members/#check-organization-membership-for-a-user
+func (c *Client) IsOrganizationMember(ctx context.Context, organization, token string) (bool, error) {
+	ok, _, err := c.withToken(ctx, token).Organizations.IsMember(ctx, organization, "")
+	return ok, err
+}
+
+// IsTeamMember reports whether name is a member of the team identified by
+// teamID, using token to authenticate.
+//
+// https://developer.github.com/v3/teams/members/#get-team-membership-for-a-user
+func (c *Client) IsTeamMember(ctx context.Context, teamID, name, token string) (bool, error) {
+	id, err := strconv.ParseInt(teamID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parsing team id %q: %v", teamID, err)
+	}
+
+	membership, resp, err := c.withToken(ctx, token).Organizations.GetTeamMembership(ctx, id, name)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return membership.GetState() == "active", nil
+}
+
+// CreateInstallationToken exchanges appJWT, a JWT signed with a GitHub
+// App's private key, for an installation access token.
+//
+// https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app
+func (c *Client) CreateInstallationToken(ctx context.Context, appID, installationID int64, appJWT string) (string, error) {
+	token, _, err := c.withToken(ctx, appJWT).Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return "", err
+	}
+	return token.GetToken(), nil
+}
+
+// withToken returns a github.Client that authenticates requests with token
+// as an OAuth2 bearer token, layered on top of c's configured http.Client
+// (and therefore its transport, proxy, or custom base URL) rather than a
+// fresh http.DefaultClient. ctx only carries c's http.Client to oauth2;
+// cancellation and deadlines come from the ctx passed to each request.
+func (c *Client) withToken(ctx context.Context, token string) *github.Client {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.httpClient)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	client := github.NewClient(oauth2.NewClient(ctx, ts))
+	client.BaseURL = c.github.BaseURL
+	return client
+}
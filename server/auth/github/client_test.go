@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestClient_GetUser_withToken verifies that withToken's per-call client
+// keeps c's base URL (here, a test server's) rather than talking to
+// api.github.com, and that it sends token as a bearer token.
+func TestClient_GetUser_withToken(t *testing.T) {
+	var gotAuth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"login": "ejholmes"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient(http.DefaultClient)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.github.BaseURL = baseURL
+
+	u, err := c.GetUser(context.Background(), "sometoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Login != "ejholmes" {
+		t.Errorf("Login = %q, want %q", u.Login, "ejholmes")
+	}
+	if gotAuth != "Bearer sometoken" {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, "Bearer sometoken")
+	}
+}
+
+// TestClient_GetUser_ctxCancelled verifies that GetUser honors the ctx
+// passed to it: a call made with an already cancelled ctx fails.
+func TestClient_GetUser_ctxCancelled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"login": "ejholmes"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := NewClient(http.DefaultClient)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.github.BaseURL = baseURL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.GetUser(ctx, "sometoken"); err == nil {
+		t.Error("GetUser with a cancelled context: got nil error, want non-nil")
+	}
+}
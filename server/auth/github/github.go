@@ -3,20 +3,46 @@
 package github
 
 import (
+	"context"
+	"crypto/rsa"
 	"fmt"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/remind101/empire"
 	"github.com/remind101/empire/server/auth"
+	"golang.org/x/oauth2"
+	oauth2github "golang.org/x/oauth2/github"
 )
 
-// Authorizer is an implementation of the auth.Authenticator interface backed by
-// GitHub's Non-Web Application Flow, which can be found at
+// TokenSource provides the GitHub access token to use when making
+// authorization checks for a user. Authenticator implementations populate
+// empire.User.GitHubToken with whatever token they obtained (a personal
+// OAuth2 token, or a GitHub App installation token), so the default
+// TokenSource simply reads it back from there.
+type TokenSource interface {
+	Token(ctx context.Context, user *empire.User) (string, error)
+}
+
+// userTokenSource is the default TokenSource, which reads the token that an
+// Authenticator already stored on the user.
+type userTokenSource struct{}
+
+func (userTokenSource) Token(ctx context.Context, user *empire.User) (string, error) {
+	return user.GitHubToken, nil
+}
+
+// Authenticator is an implementation of the auth.Authenticator interface
+// backed by GitHub's Non-Web Application Flow, which can be found at
 // http://goo.gl/onpQKM.
+//
+// Deprecated: GitHub removed the Non-Web Application Flow in 2020. Use
+// OAuth2Authenticator instead.
 type Authenticator struct {
 	// OAuth2 configuration (client id, secret, scopes, etc).
 	client interface {
-		CreateAuthorization(CreateAuthorizationOptions) (*Authorization, error)
-		GetUser(token string) (*User, error)
+		CreateAuthorization(context.Context, CreateAuthorizationOptions) (*Authorization, error)
+		GetUser(context.Context, string) (*User, error)
 	}
 }
 
@@ -26,8 +52,8 @@ func NewAuthenticator(c *Client) *Authenticator {
 	return &Authenticator{client: c}
 }
 
-func (a *Authenticator) Authenticate(username, password, otp string) (*empire.User, error) {
-	authorization, err := a.client.CreateAuthorization(CreateAuthorizationOptions{
+func (a *Authenticator) Authenticate(ctx context.Context, username, password, otp string) (*empire.User, error) {
+	authorization, err := a.client.CreateAuthorization(ctx, CreateAuthorizationOptions{
 		Username: username,
 		Password: password,
 		OTP:      otp,
@@ -43,7 +69,7 @@ func (a *Authenticator) Authenticate(username, password, otp string) (*empire.Us
 		}
 	}
 
-	u, err := a.client.GetUser(authorization.Token)
+	u, err := a.client.GetUser(ctx, authorization.Token)
 	if err != nil {
 		return nil, err
 	}
@@ -54,13 +80,146 @@ func (a *Authenticator) Authenticate(username, password, otp string) (*empire.Us
 	}, nil
 }
 
+// OAuth2Authenticator is an implementation of an Authenticator backed by
+// GitHub's standard OAuth2 web application and device authorization flows.
+//
+// https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps
+type OAuth2Authenticator struct {
+	// Config holds the client id, secret, scopes and endpoint used to
+	// perform the OAuth2 flow. Config.Endpoint should generally be set to
+	// golang.org/x/oauth2/github.Endpoint.
+	Config *oauth2.Config
+
+	client interface {
+		GetUser(ctx context.Context, token string) (*User, error)
+	}
+}
+
+// NewOAuth2Authenticator returns a new OAuth2Authenticator that exchanges
+// authorization codes for tokens using config, and resolves the resulting
+// user using c.
+func NewOAuth2Authenticator(config *oauth2.Config, c *Client) *OAuth2Authenticator {
+	if config.Endpoint == (oauth2.Endpoint{}) {
+		config.Endpoint = oauth2github.Endpoint
+	}
+	return &OAuth2Authenticator{Config: config, client: c}
+}
+
+// Exchange completes the web application flow by exchanging an
+// authorization code (obtained from GitHub's /login/oauth/authorize
+// redirect) for an access token.
+func (a *OAuth2Authenticator) Exchange(ctx context.Context, code string) (*empire.User, error) {
+	token, err := a.Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging oauth2 code: %v", err)
+	}
+	return a.userForToken(ctx, token.AccessToken)
+}
+
+// DeviceAuth starts the device authorization flow, returning the
+// verification URI and user code that should be presented to the user.
+func (a *OAuth2Authenticator) DeviceAuth(ctx context.Context) (*oauth2.DeviceAuthResponse, error) {
+	return a.Config.DeviceAuth(ctx)
+}
+
+// DeviceAccessToken polls GitHub until the user has approved the device
+// authorization request represented by da, then resolves the user.
+func (a *OAuth2Authenticator) DeviceAccessToken(ctx context.Context, da *oauth2.DeviceAuthResponse) (*empire.User, error) {
+	token, err := a.Config.DeviceAccessToken(ctx, da)
+	if err != nil {
+		return nil, fmt.Errorf("polling for device access token: %v", err)
+	}
+	return a.userForToken(ctx, token.AccessToken)
+}
+
+func (a *OAuth2Authenticator) userForToken(ctx context.Context, token string) (*empire.User, error) {
+	u, err := a.client.GetUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &empire.User{
+		Name:        u.Login,
+		GitHubToken: token,
+	}, nil
+}
+
+// GitHubAppTokenSource is a TokenSource that authenticates as a GitHub App
+// installation, by signing a short lived JWT with the App's private key and
+// exchanging it for an installation access token.
+//
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/authenticating-as-a-github-app-installation
+type GitHubAppTokenSource struct {
+	// AppID is the numeric id of the GitHub App.
+	AppID int64
+
+	// InstallationID is the numeric id of the installation to request a
+	// token for.
+	InstallationID int64
+
+	// PrivateKey is the GitHub App's private key, used to sign the JWT
+	// used to authenticate as the app.
+	PrivateKey *rsa.PrivateKey
+
+	client interface {
+		CreateInstallationToken(ctx context.Context, appID, installationID int64, jwt string) (string, error)
+	}
+}
+
+// NewGitHubAppTokenSource returns a new GitHubAppTokenSource.
+func NewGitHubAppTokenSource(c *Client, appID, installationID int64, privateKey *rsa.PrivateKey) *GitHubAppTokenSource {
+	return &GitHubAppTokenSource{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+		client:         c,
+	}
+}
+
+// Token mints a new App JWT and exchanges it for an installation access
+// token, which it also stores on user.GitHubToken. GitHub App installation
+// tokens are short lived (1 hour), so callers should treat them as single
+// use rather than caching them.
+func (t *GitHubAppTokenSource) Token(ctx context.Context, user *empire.User) (string, error) {
+	jwt, err := t.signedJWT()
+	if err != nil {
+		return "", fmt.Errorf("signing app jwt: %v", err)
+	}
+
+	token, err := t.client.CreateInstallationToken(ctx, t.AppID, t.InstallationID, jwt)
+	if err != nil {
+		return "", fmt.Errorf("creating installation token: %v", err)
+	}
+
+	if user != nil {
+		user.GitHubToken = token
+	}
+
+	return token, nil
+}
+
+func (t *GitHubAppTokenSource) signedJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-1 * time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+		Issuer:    fmt.Sprintf("%d", t.AppID),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(t.PrivateKey)
+}
+
 // OrganizationAuthorizer is an implementation of the auth.Authorizer interface
 // that checks that the user is a member of the given GitHub organization.
 type OrganizationAuthorizer struct {
 	Organization string
 
+	// Tokens provides the GitHub token to check membership with. Defaults
+	// to reading user.GitHubToken, which is populated by Authenticator,
+	// OAuth2Authenticator or a GitHubAppTokenSource.
+	Tokens TokenSource
+
 	client interface {
-		IsOrganizationMember(organization, token string) (bool, error)
+		IsOrganizationMember(ctx context.Context, organization, token string) (bool, error)
 	}
 }
 
@@ -69,13 +228,18 @@ func NewOrganizationAuthorizer(c *Client) *OrganizationAuthorizer {
 	return &OrganizationAuthorizer{client: c}
 }
 
-func (a *OrganizationAuthorizer) Authorize(user *empire.User) error {
+func (a *OrganizationAuthorizer) Authorize(ctx context.Context, user *empire.User) error {
 	if a.Organization == "" {
 		// Probably a configuration error
 		panic("no organization set")
 	}
 
-	ok, err := a.client.IsOrganizationMember(a.Organization, user.GitHubToken)
+	token, err := a.tokens().Token(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	ok, err := a.client.IsOrganizationMember(ctx, a.Organization, token)
 	if err != nil {
 		return err
 	}
@@ -89,13 +253,25 @@ func (a *OrganizationAuthorizer) Authorize(user *empire.User) error {
 	return nil
 }
 
+func (a *OrganizationAuthorizer) tokens() TokenSource {
+	if a.Tokens == nil {
+		return userTokenSource{}
+	}
+	return a.Tokens
+}
+
 // TeamAuthorizer is an implementation of the auth.Authorizer interface that
 // checks that the user is a member of the given GitHub team.
 type TeamAuthorizer struct {
 	TeamID string
 
+	// Tokens provides the GitHub token to check membership with. Defaults
+	// to reading user.GitHubToken, which is populated by Authenticator,
+	// OAuth2Authenticator or a GitHubAppTokenSource.
+	Tokens TokenSource
+
 	client interface {
-		IsTeamMember(teamID, name string, token string) (bool, error)
+		IsTeamMember(ctx context.Context, teamID, name string, token string) (bool, error)
 	}
 }
 
@@ -103,12 +279,17 @@ func NewTeamAuthorizer(c *Client) *TeamAuthorizer {
 	return &TeamAuthorizer{client: c}
 }
 
-func (a *TeamAuthorizer) Authorize(user *empire.User) error {
+func (a *TeamAuthorizer) Authorize(ctx context.Context, user *empire.User) error {
 	if a.TeamID == "" {
 		panic("no team id set")
 	}
 
-	ok, err := a.client.IsTeamMember(a.TeamID, user.Name, user.GitHubToken)
+	token, err := a.tokens().Token(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	ok, err := a.client.IsTeamMember(ctx, a.TeamID, user.Name, token)
 	if err != nil {
 		return err
 	}
@@ -121,3 +302,10 @@ func (a *TeamAuthorizer) Authorize(user *empire.User) error {
 
 	return nil
 }
+
+func (a *TeamAuthorizer) tokens() TokenSource {
+	if a.Tokens == nil {
+		return userTokenSource{}
+	}
+	return a.Tokens
+}
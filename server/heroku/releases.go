@@ -45,6 +45,8 @@ func newReleases(rs []*empire.Release) []*Release {
 }
 
 func (h *Server) GetRelease(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
 	a, err := h.findApp(r)
 	if err != nil {
 		return err
@@ -56,7 +58,7 @@ func (h *Server) GetRelease(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	rel, err := h.ReleasesFind(empire.ReleasesQuery{App: a, Version: &vers})
+	rel, err := h.ReleasesFind(ctx, empire.ReleasesQuery{App: a, Version: &vers})
 	if err != nil {
 		return err
 	}
@@ -66,6 +68,8 @@ func (h *Server) GetRelease(w http.ResponseWriter, r *http.Request) error {
 }
 
 func (h *Server) GetReleases(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
 	a, err := h.findApp(r)
 	if err != nil {
 		return err
@@ -76,7 +80,7 @@ func (h *Server) GetReleases(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	rels, err := h.Releases(empire.ReleasesQuery{App: a, Range: rangeHeader})
+	rels, err := h.Releases(ctx, empire.ReleasesQuery{App: a, Range: rangeHeader})
 	if err != nil {
 		return err
 	}
@@ -98,6 +102,19 @@ func (p *PostReleasesForm) ReleaseVersion() (int, error) {
 	return vers, nil
 }
 
+// PostReleases implements rollback. Status reporting (pending ->
+// success/failure against the merge commit a rollback produces) piggybacks
+// on Storage.ReleasesCreate's own reportStatus calls rather than happening
+// here, since h.Rollback's implementation is what decides whether a
+// rollback goes through Storage.ReleasesCreate at all.
+//
+// TODO(ejholmes): surfacing that status back in this handler's response
+// body, as chunk0-4 originally asked for, needs empire.Release to carry a
+// SHA/status back from Storage - it currently doesn't, and adding one
+// means a decision from whoever owns the core empire package about
+// whether that belongs on empire.Release itself or behind a narrower
+// interface Storage can satisfy optionally. Don't treat chunk0-4 as fully
+// done until that's resolved.
 func (h *Server) PostReleases(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// LastApplied tracks the most recently reconciled commit SHA for each app,
+// so that redelivered or overlapping webhooks don't apply the same state
+// twice. It's an optimization, not a correctness mechanism: ReconcileApp
+// always diffs against the push's own "before" SHA when it has no record
+// for an app, so a restart (which empties an in-memory LastApplied) can't
+// cause it to treat an app's entire configuration as newly changed.
+//
+// When backed by a file (see NewPersistentLastApplied), it additionally
+// avoids redoing work already applied before a restart, by persisting
+// every update to disk.
+type LastApplied struct {
+	mu   sync.Mutex
+	seen map[string]string
+	path string
+}
+
+// NewLastApplied returns an empty, in-memory LastApplied.
+func NewLastApplied() *LastApplied {
+	return &LastApplied{seen: make(map[string]string)}
+}
+
+// NewPersistentLastApplied returns a LastApplied that loads its state from
+// path, if it exists, and rewrites path after every Set.
+func NewPersistentLastApplied(path string) (*LastApplied, error) {
+	l := &LastApplied{seen: make(map[string]string), path: path}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &l.seen); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get returns the last SHA applied for app, or "" if none has been applied
+// yet.
+func (l *LastApplied) Get(app string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seen[app]
+}
+
+// Set records sha as the last SHA applied for app, persisting it to disk if
+// this LastApplied was created with NewPersistentLastApplied.
+func (l *LastApplied) Set(app, sha string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seen[app] = sha
+
+	if l.path == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(l.seen)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, raw, 0644)
+}
@@ -0,0 +1,53 @@
+package github
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLastApplied_GetSet(t *testing.T) {
+	l := NewLastApplied()
+
+	if got := l.Get("myapp"); got != "" {
+		t.Fatalf("Get on an empty LastApplied = %q, want \"\"", got)
+	}
+
+	if err := l.Set("myapp", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Get("myapp"); got != "abc123" {
+		t.Fatalf("Get = %q, want %q", got, "abc123")
+	}
+}
+
+func TestNewPersistentLastApplied_roundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "last-applied.json")
+
+	l, err := NewPersistentLastApplied(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Set("myapp", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewPersistentLastApplied(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Get("myapp"); got != "abc123" {
+		t.Fatalf("Get after reload = %q, want %q", got, "abc123")
+	}
+}
+
+func TestNewPersistentLastApplied_missingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.json")
+
+	l, err := NewPersistentLastApplied(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Get("myapp"); got != "" {
+		t.Fatalf("Get on a fresh LastApplied = %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,66 @@
+package github
+
+import "sync"
+
+// defaultLimit is the number of pending tasks buffered per app before
+// Enqueue starts rejecting new work for that app.
+const defaultLimit = 8
+
+// Queue is a bounded, in-process work queue that serializes tasks per app:
+// tasks enqueued for the same app always run one at a time, in submission
+// order, while tasks for different apps run concurrently. It exists so
+// that a burst of webhook deliveries can't reconcile the same app's state
+// out of order, without forcing unrelated apps to wait behind it.
+type Queue struct {
+	// Limit bounds how many pending tasks are buffered per app. Defaults
+	// to 8.
+	Limit int
+
+	mu    sync.Mutex
+	lanes map[string]chan func()
+}
+
+// NewQueue returns an empty Queue.
+func NewQueue() *Queue {
+	return &Queue{lanes: make(map[string]chan func())}
+}
+
+// Enqueue schedules fn to run after any previously enqueued tasks for app.
+// It reports whether fn was enqueued; it returns false if app's queue is
+// full, in which case the caller should treat the task as dropped (the
+// next successful delivery for app will still converge it, since
+// reconciliation reads the latest state rather than replaying history).
+func (q *Queue) Enqueue(app string, fn func()) bool {
+	select {
+	case q.lane(app) <- fn:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *Queue) lane(app string) chan func() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.lanes == nil {
+		q.lanes = make(map[string]chan func())
+	}
+	l, ok := q.lanes[app]
+	if !ok {
+		limit := q.Limit
+		if limit <= 0 {
+			limit = defaultLimit
+		}
+		l = make(chan func(), limit)
+		q.lanes[app] = l
+		go drain(l)
+	}
+	return l
+}
+
+func drain(tasks chan func()) {
+	for fn := range tasks {
+		fn()
+	}
+}
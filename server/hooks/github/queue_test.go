@@ -0,0 +1,103 @@
+package github
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue_sameAppRunsInOrder(t *testing.T) {
+	q := NewQueue()
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		i := i
+		wg.Add(1)
+		if !q.Enqueue("myapp", func() {
+			defer wg.Done()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}) {
+			t.Fatalf("Enqueue(%d) returned false", i)
+		}
+	}
+
+	if !waitTimeout(&wg, time.Second) {
+		t.Fatal("timed out waiting for queued tasks")
+	}
+
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("order = %v, want tasks to run in submission order", order)
+		}
+	}
+}
+
+func TestQueue_differentAppsRunConcurrently(t *testing.T) {
+	q := NewQueue()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	block := func() {
+		started <- struct{}{}
+		<-release
+	}
+
+	if !q.Enqueue("app-a", block) {
+		t.Fatal("Enqueue(app-a) returned false")
+	}
+	if !q.Enqueue("app-b", block) {
+		t.Fatal("Enqueue(app-b) returned false")
+	}
+
+	timeout := time.After(time.Second)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-timeout:
+			t.Fatal("app-b's task never started; apps are serialized against each other")
+		}
+	}
+	close(release)
+}
+
+func TestQueue_enqueueReturnsFalseWhenFull(t *testing.T) {
+	q := &Queue{Limit: 1}
+
+	started := make(chan struct{})
+	block := make(chan struct{})
+	if !q.Enqueue("myapp", func() {
+		close(started)
+		<-block
+	}) {
+		t.Fatal("first Enqueue returned false")
+	}
+	<-started // wait until the first task has been dequeued and is running
+
+	if !q.Enqueue("myapp", func() {}) {
+		t.Fatal("second Enqueue (filling the buffer) returned false")
+	}
+	if q.Enqueue("myapp", func() {}) {
+		t.Error("Enqueue on a full lane returned true, want false")
+	}
+	close(block)
+}
+
+func waitTimeout(wg *sync.WaitGroup, d time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
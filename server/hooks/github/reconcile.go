@@ -0,0 +1,112 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/server/storage/scm"
+)
+
+// hooksUser identifies the actor recorded against releases, config changes
+// and scale events that Reconciler applies on behalf of a push, the same
+// way Storage.commitAuthor identifies the "Empire" committer on the git
+// side of this loop.
+var hooksUser = &empire.User{Name: "empire-hooks"}
+
+// Reconciler applies the configuration stored in a repository (via
+// scm.Storage) to Empire's running state. It's the read side of the
+// write-through-git storage implemented by scm.Storage: ReleasesCreate
+// writes Empire's state out to git, and Reconciler reads changes made
+// directly to git (by hand, or via a merged PR) back into Empire, so that
+// both paths converge on the same state.
+type Reconciler struct {
+	Empire  *empire.Empire
+	Storage *scm.Storage
+
+	// LastApplied tracks the most recently reconciled SHA per app.
+	// Required; NewReconciler sets it to an in-memory-only LastApplied.
+	// Set it to one created with NewPersistentLastApplied to survive a
+	// process restart.
+	LastApplied *LastApplied
+}
+
+// NewReconciler returns a Reconciler that applies changes read from
+// storage to e.
+func NewReconciler(e *empire.Empire, storage *scm.Storage) *Reconciler {
+	return &Reconciler{
+		Empire:      e,
+		Storage:     storage,
+		LastApplied: NewLastApplied(),
+	}
+}
+
+// ReconcileApp brings app up to date with its configuration at after, if it
+// isn't already. It diffs against before, the previous SHA of the ref as
+// reported by the push that's triggering this call, rather than solely
+// against r.LastApplied: that keeps reconciliation correct across a
+// process restart, when LastApplied has forgotten everything but the push
+// itself still only touched what it touched. Each of deploy, set and
+// scale is gated independently on whether its own file actually changed,
+// so a hand-edit to app.env or services.json that doesn't also bump
+// VERSION still converges.
+func (r *Reconciler) ReconcileApp(ctx context.Context, name, before, after string) error {
+	if r.LastApplied.Get(name) == after {
+		return nil
+	}
+
+	baseline := before
+	if last := r.LastApplied.Get(name); last != "" {
+		baseline = last
+	}
+
+	var prev empire.App
+	if baseline != "" {
+		loaded, err := r.Storage.AppAt(ctx, baseline, name)
+		if err != nil {
+			return fmt.Errorf("loading previous state of %q: %v", name, err)
+		}
+		prev = *loaded
+	}
+
+	app, err := r.Storage.AppAt(ctx, after, name)
+	if err != nil {
+		return fmt.Errorf("loading %q at %s: %v", name, after, err)
+	}
+
+	if prev.Image == nil || app.Image.String() != prev.Image.String() {
+		if _, err := r.Empire.Deploy(ctx, empire.DeployOpts{
+			User:  hooksUser,
+			App:   app,
+			Image: *app.Image,
+		}); err != nil {
+			return fmt.Errorf("deploying %q: %v", name, err)
+		}
+	}
+
+	if !reflect.DeepEqual(prev.Environment, app.Environment) {
+		if _, err := r.Empire.Set(ctx, empire.SetOpts{
+			User: hooksUser,
+			App:  app,
+			Vars: app.Environment,
+		}); err != nil {
+			return fmt.Errorf("setting config for %q: %v", name, err)
+		}
+	}
+
+	if !reflect.DeepEqual(prev.Formation, app.Formation) {
+		if _, err := r.Empire.Scale(ctx, empire.ScaleOpts{
+			User:      hooksUser,
+			App:       app,
+			Formation: app.Formation,
+		}); err != nil {
+			return fmt.Errorf("scaling %q: %v", name, err)
+		}
+	}
+
+	if err := r.LastApplied.Set(name, after); err != nil {
+		return fmt.Errorf("persisting last applied sha for %q: %v", name, err)
+	}
+	return nil
+}
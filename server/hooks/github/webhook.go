@@ -0,0 +1,183 @@
+// Package github implements a GitHub webhook handler that turns Empire's
+// write-through-git storage (see server/storage/scm) into a pull-based
+// GitOps loop: when a push lands on the ref that Storage tracks, this
+// package reconciles Empire's running state with the pushed tree, so that
+// config changes and deploys made directly against the repository (by
+// hand, or via a merged PR) take effect the same as if they'd gone
+// through the Empire API.
+package github
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/server/storage/scm"
+)
+
+// Handler is an http.Handler that receives GitHub "push" webhooks.
+//
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads#push
+type Handler struct {
+	// Secret is the webhook secret configured on the GitHub repository
+	// or organization, used to verify the "X-Hub-Signature-256" header.
+	// Required; requests that don't verify are rejected.
+	Secret []byte
+
+	// Ref is the ref that pushes are reconciled from; pushes to any
+	// other ref are ignored. Defaults to Reconciler.Storage.Ref.
+	Ref string
+
+	Reconciler *Reconciler
+	Queue      *Queue
+}
+
+// NewHandler returns a Handler that verifies deliveries with secret and
+// reconciles them with reconciler.
+func NewHandler(secret []byte, reconciler *Reconciler) *Handler {
+	return &Handler{
+		Secret:     secret,
+		Reconciler: reconciler,
+		Queue:      NewQueue(),
+	}
+}
+
+// zeroSHA is the all-zeros SHA GitHub reports as "before" when a push
+// creates a new branch, i.e. there is no previous state to diff against.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// pushEvent is the subset of GitHub's push event payload that we care
+// about.
+type pushEvent struct {
+	Ref     string      `json:"ref"`
+	Before  string      `json:"before"`
+	After   string      `json:"after"`
+	Commits pushCommits `json:"commits"`
+}
+
+// pushCommit is the subset of a single commit within a push event payload
+// that we care about: the paths it touched, used to work out which apps a
+// push actually affects.
+type pushCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+type pushCommits []pushCommit
+
+// touches reports whether any path changed across commits starts with
+// prefix.
+func (commits pushCommits) touches(prefix string) bool {
+	for _, c := range commits {
+		for _, paths := range [][]string{c.Added, c.Removed, c.Modified} {
+			for _, p := range paths {
+				if strings.HasPrefix(p, prefix) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.verify(r.Header.Get("X-Hub-Signature-256"), body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// We only care about pushes; ack anything else so GitHub doesn't
+	// retry it.
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var event pushEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ref := h.Ref
+	if ref == "" {
+		ref = h.Reconciler.Storage.Ref
+	}
+	if event.Ref != ref || event.After == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ctx := r.Context()
+	apps, err := h.Reconciler.Storage.Apps(ctx, empire.AppsQuery{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("listing apps: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	before := event.Before
+	if before == zeroSHA {
+		before = ""
+	}
+
+	// Reconciliation can take a while (it may deploy, set config, or
+	// scale), so we enqueue it and return immediately. The queue
+	// serializes work per app and runs with a context of its own,
+	// since r's will be canceled as soon as we respond.
+	sha := event.After
+	for _, app := range apps {
+		name := app.Name
+
+		// Only reconcile apps whose directory this push actually
+		// touched; otherwise every push would re-diff the entire
+		// app fleet.
+		prefix := h.Reconciler.Storage.Path(name) + scm.DirectorySeparator
+		if !event.Commits.touches(prefix) {
+			continue
+		}
+
+		if !h.Queue.Enqueue(name, func() {
+			if err := h.Reconciler.ReconcileApp(context.Background(), name, before, sha); err != nil {
+				log.Printf("hooks/github: reconciling %s@%s: %v", name, sha, err)
+			}
+		}) {
+			log.Printf("hooks/github: queue full for %s, dropping %s", name, sha)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify reports whether header is a valid "sha256=<hmac>" signature of
+// body under h.Secret.
+func (h *Handler) verify(header string, body []byte) bool {
+	const prefix = "sha256="
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(header, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.Secret)
+	mac.Write(body)
+
+	return hmac.Equal(sig, mac.Sum(nil))
+}
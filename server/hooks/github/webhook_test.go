@@ -0,0 +1,62 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandler_verify(t *testing.T) {
+	h := &Handler{Secret: []byte("s3cr3t")}
+	body := []byte(`{"ref":"refs/heads/master"}`)
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"valid signature", sign(h.Secret, body), true},
+		{"wrong secret", sign([]byte("wrong"), body), false},
+		{"missing prefix", hex.EncodeToString([]byte("not-a-valid-sig")), false},
+		{"invalid hex", "sha256=not-hex", false},
+		{"empty header", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.verify(tt.header, body); got != tt.want {
+				t.Errorf("verify(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_verify_tamperedBody(t *testing.T) {
+	h := &Handler{Secret: []byte("s3cr3t")}
+	header := sign(h.Secret, []byte(`{"ref":"refs/heads/master"}`))
+
+	if h.verify(header, []byte(`{"ref":"refs/heads/evil"}`)) {
+		t.Error("verify reported a valid signature for a tampered body")
+	}
+}
+
+func TestPushCommits_touches(t *testing.T) {
+	commits := pushCommits{
+		{Added: []string{"other/VERSION"}},
+		{Modified: []string{"myapp/app.env"}},
+	}
+
+	if !commits.touches("myapp/") {
+		t.Error("touches(\"myapp/\") = false, want true")
+	}
+	if commits.touches("unrelated/") {
+		t.Error("touches(\"unrelated/\") = true, want false")
+	}
+}
@@ -0,0 +1,267 @@
+package scm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	bitbucketv1 "github.com/gfleury/go-bitbucket-v1"
+)
+
+// bitbucketForge is a Forge implementation backed by the Bitbucket Server
+// (formerly Stash) REST API.
+//
+// Bitbucket Server has no Git Data API equivalent to GitHub/Gitea's
+// tree/commit primitives; instead, files are edited one at a time through
+// the "browse" endpoint, which creates a commit on the given branch as a
+// side effect. CreateTree is therefore a no-op that just remembers the
+// entries for CreateCommit to apply.
+//
+// https://docs.atlassian.com/bitbucket-server/rest/latest/bitbucket-rest.html
+type bitbucketForge struct {
+	client *bitbucketv1.APIClient
+
+	// A single bitbucketForge is shared across concurrent
+	// ReleasesCreate calls (e.g. different apps deploying at once), so
+	// entries needs the same locking gitlabForge uses for its
+	// equivalent trees map.
+	mu      sync.Mutex
+	entries map[string][]TreeEntry
+
+	// Bitbucket Server's content and pull request APIs both take a
+	// branch name, not a SHA, so CreateCommit creates a scratch branch to
+	// stand in for the anonymous commit Storage wants and records it
+	// here, keyed by the SHA of the commit it produced, so that Merge can
+	// find it again from mr.Head.
+	branches map[string]string
+}
+
+// NewBitbucketForge returns a Forge that talks to a Bitbucket Server
+// instance at baseURL, authenticated with a personal access token.
+func NewBitbucketForge(baseURL, token string) Forge {
+	cfg := bitbucketv1.NewConfiguration(baseURL)
+	ctx := bitbucketv1.NewAPIKeyContextWithAuth(token)
+	return &bitbucketForge{
+		client:   bitbucketv1.NewAPIClient(ctx, cfg),
+		entries:  make(map[string][]TreeEntry),
+		branches: make(map[string]string),
+	}
+}
+
+// The generated bitbucketv1 client bakes its auth context in at
+// construction time and has no per-call context override, so ctx is
+// accepted here only to satisfy the Forge interface.
+func (f *bitbucketForge) GetRef(ctx context.Context, owner, repo, name string) (*Ref, error) {
+	resp, err := f.client.DefaultApi.GetBranches(owner, repo, map[string]interface{}{
+		"filterText": name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	branches, err := bitbucketv1.GetBranchesResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range branches {
+		if b.DisplayId == name || b.Id == name {
+			return &Ref{Name: name, SHA: b.LatestCommit}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *bitbucketForge) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	resp, err := f.client.DefaultApi.GetCommit(owner, repo, sha, nil)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := bitbucketv1.GetCommitResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	return fromBitbucketCommit(&commit), nil
+}
+
+// CreateTree has no Bitbucket Server equivalent; it stashes the entries
+// under a synthetic tree SHA derived from baseTree and entries, so that
+// CreateCommit can apply them as individual file edits on a scratch branch.
+func (f *bitbucketForge) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	sha := treeSHA(baseTree, entries)
+
+	f.mu.Lock()
+	f.entries[sha] = entries
+	f.mu.Unlock()
+
+	return &Tree{SHA: sha, Entries: entries}, nil
+}
+
+func (f *bitbucketForge) CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error) {
+	if len(commit.Parents) == 0 {
+		return nil, fmt.Errorf("bitbucket: commit has no parent to branch from")
+	}
+
+	// commit.Parents[0] is a SHA, but the content API's "branch" param
+	// must name an existing branch. Create a scratch branch at that SHA
+	// to stand in for it; Merge deletes it again once it's been consumed.
+	branch, err := f.createScratchBranch(owner, repo, commit.TreeSHA, commit.Parents[0])
+	if err != nil {
+		return nil, fmt.Errorf("creating scratch branch: %v", err)
+	}
+
+	f.mu.Lock()
+	entries := f.entries[commit.TreeSHA]
+	delete(f.entries, commit.TreeSHA)
+	f.mu.Unlock()
+
+	var lastSHA string
+	for _, e := range entries {
+		resp, err := f.client.DefaultApi.UpdateContent_43(owner, repo, e.Path, bytes.NewReader([]byte(e.Content)), io.Discard, map[string]interface{}{
+			"branch":  branch,
+			"message": commit.Message,
+		})
+		if err != nil {
+			f.deleteBranch(owner, repo, branch)
+			return nil, fmt.Errorf("updating %q: %v", e.Path, err)
+		}
+		fileCommit, err := bitbucketv1.GetCommitResponse(resp)
+		if err != nil {
+			f.deleteBranch(owner, repo, branch)
+			return nil, err
+		}
+		lastSHA = fileCommit.Id
+	}
+
+	f.mu.Lock()
+	f.branches[lastSHA] = branch
+	f.mu.Unlock()
+
+	return &Commit{SHA: lastSHA, Message: commit.Message, Parents: commit.Parents}, nil
+}
+
+// createScratchBranch creates a throwaway branch at sha, named after
+// treeSHA so that two concurrent releases (which always touch a different
+// tree) never collide. It's the branch CreateCommit edits files onto and
+// Merge later opens a pull request from.
+func (f *bitbucketForge) createScratchBranch(owner, repo, treeSHA, sha string) (string, error) {
+	name := "empire/" + treeSHA
+	_, err := f.client.DefaultApi.CreateBranch(owner, repo, bitbucketv1.CreateBranchRequest{
+		Name:       name,
+		StartPoint: sha,
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// deleteBranch removes a scratch branch created by createScratchBranch.
+// Errors are swallowed: the branch is disposable scratch state, not
+// something callers can act on.
+func (f *bitbucketForge) deleteBranch(owner, repo, name string) {
+	f.client.DefaultApi.DeleteBranch(owner, repo, bitbucketv1.DeleteBranchRequest{Name: name})
+}
+
+func (f *bitbucketForge) Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error) {
+	f.mu.Lock()
+	branch, ok := f.branches[mr.Head]
+	delete(f.branches, mr.Head)
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("bitbucket: no scratch branch found for commit %q", mr.Head)
+	}
+	defer f.deleteBranch(owner, repo, branch)
+
+	pr, err := f.client.DefaultApi.CreatePullRequest(owner, repo, bitbucketv1.PullRequest{
+		Title: mr.Message,
+		FromRef: bitbucketv1.PullRequestRef{
+			Id: branch,
+		},
+		ToRef: bitbucketv1.PullRequestRef{
+			Id: mr.Base,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	prResp, err := bitbucketv1.GetPullRequestResponse(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := f.client.DefaultApi.Merge(owner, repo, int(prResp.ID), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	mergedPR, err := bitbucketv1.GetPullRequestResponse(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Commit{SHA: mergedPR.FromRef.LatestCommit}, nil
+}
+
+func (f *bitbucketForge) ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error) {
+	resp, err := f.client.DefaultApi.GetCommits(owner, repo, map[string]interface{}{
+		"until": opts.Ref,
+		"path":  opts.Path,
+	})
+	if err != nil {
+		return nil, err
+	}
+	commits, err := bitbucketv1.GetCommitsResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Commit, len(commits))
+	for i, c := range commits {
+		out[i] = fromBitbucketCommit(&c)
+	}
+	return out, nil
+}
+
+func (f *bitbucketForge) GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error) {
+	resp, err := f.client.DefaultApi.GetContent_16(owner, repo, path, map[string]interface{}{
+		"at": ref,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines, err := bitbucketv1.GetRawContentResponse(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lines != nil {
+		return &Content{Path: path, Type: "file", Content: lines}, nil, nil
+	}
+
+	entries, err := bitbucketv1.GetFilesResponse(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	var dir []*Content
+	for _, e := range entries {
+		dir = append(dir, &Content{Name: e, Path: path + "/" + e, Type: "dir"})
+	}
+	return nil, dir, nil
+}
+
+func fromBitbucketCommit(c *bitbucketv1.Commit) *Commit {
+	commit := &Commit{
+		SHA:     c.Id,
+		Message: c.Message,
+	}
+	for _, p := range c.Parents {
+		commit.Parents = append(commit.Parents, p.Id)
+	}
+	if c.Committer != nil {
+		commit.Committer = Signature{
+			Name:  c.Committer.Name,
+			Email: c.Committer.EmailAddress,
+		}
+	}
+	return commit
+}
@@ -0,0 +1,149 @@
+// Package scm provides a generic, forge-agnostic implementation of the
+// empire.Storage interface that stores application configuration as files in
+// a repository hosted by a Git forge (GitHub, GitLab, Gitea/Forgejo,
+// Bitbucket Server, etc).
+package scm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by a Forge implementation when the requested ref,
+// commit or path does not exist.
+var ErrNotFound = errors.New("scm: not found")
+
+// Ref represents a named reference (e.g. "heads/master") and the SHA of the
+// object it points at.
+type Ref struct {
+	Name string
+	SHA  string
+}
+
+// Signature identifies the author or committer of a Commit.
+type Signature struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+// Commit is a forge-agnostic representation of a Git commit object.
+type Commit struct {
+	SHA       string
+	TreeSHA   string
+	Message   string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+
+	// Signature is an optional ASCII-armored detached signature that
+	// should be attached to the commit when it's created.
+	Signature string
+}
+
+// TreeEntry is a single file within a Tree.
+type TreeEntry struct {
+	Path    string
+	Mode    string
+	Type    string
+	Content string
+}
+
+// Tree is a forge-agnostic representation of a Git tree object.
+type Tree struct {
+	SHA     string
+	Entries []TreeEntry
+}
+
+// Tag is a forge-agnostic representation of an annotated Git tag object.
+type Tag struct {
+	// Name is the tag name, e.g. "myapp-v42".
+	Name string
+
+	// SHA is the SHA of the object (usually a commit) the tag points at.
+	SHA string
+
+	// Message is the annotation message.
+	Message string
+
+	Tagger Signature
+
+	// Signature is an optional ASCII-armored detached signature over the
+	// canonical tag object, for GPG/SSH-signed tags.
+	Signature string
+}
+
+// TagCreator is implemented by Forges that support creating annotated Git
+// tags. It's used to tag signed releases, and is optional: Storage checks
+// for it with a type assertion on the configured Forge rather than it being
+// part of the Forge interface, since not every forge's API exposes tag
+// creation the same way.
+type TagCreator interface {
+	CreateTag(ctx context.Context, owner, repo string, tag *Tag) (*Tag, error)
+}
+
+// Content is a file or directory entry returned by Forge.GetContents.
+type Content struct {
+	Name    string
+	Path    string
+	Type    string // "file" or "dir"
+	Content []byte
+}
+
+// ListCommitsOptions filters the results of Forge.ListCommits.
+type ListCommitsOptions struct {
+	// Ref is the branch, tag or SHA to list commits from. Defaults to the
+	// repository's default branch.
+	Ref string
+
+	// Path, if set, only returns commits that touched this path.
+	Path string
+}
+
+// MergeRequest describes a request to merge Head into Base.
+type MergeRequest struct {
+	// Base is the ref that Head will be merged into (e.g. "master").
+	Base string
+
+	// Head is the SHA or ref to merge into Base.
+	Head string
+
+	// Message is used as the merge commit message, when the forge
+	// creates one.
+	Message string
+}
+
+// Forge is the interface that a Git hosting provider (GitHub, GitLab,
+// Gitea/Forgejo, Bitbucket Server, ...) must implement so that it can be used
+// as the backing store for Storage. Implementations are responsible for
+// translating these forge-agnostic operations into the provider's specific
+// API, including authentication and rate limit handling.
+type Forge interface {
+	// GetRef returns the ref matching name (e.g. "heads/master") in
+	// owner/repo.
+	GetRef(ctx context.Context, owner, repo, name string) (*Ref, error)
+
+	// GetCommit returns the commit identified by sha in owner/repo.
+	GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error)
+
+	// CreateTree creates a new tree in owner/repo, based on baseTree,
+	// with entries applied on top of it.
+	CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error)
+
+	// CreateCommit creates a new commit in owner/repo from commit, which
+	// has at least Message, TreeSHA and Parents populated.
+	CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error)
+
+	// Merge merges mr.Head into mr.Base in owner/repo, returning the
+	// resulting merge commit.
+	Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error)
+
+	// ListCommits returns commits in owner/repo matching opts, most
+	// recent first.
+	ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error)
+
+	// GetContents returns the contents of a file at path, or, if path is
+	// a directory, a listing of its direct children.
+	GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error)
+}
@@ -0,0 +1,277 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+
+	"code.gitea.io/sdk/gitea"
+)
+
+// giteaForge is a Forge implementation backed by the Gitea/Forgejo API,
+// which, relevantly, mirrors GitHub's Git Data and Contents APIs closely
+// enough to share most of its shape.
+//
+// https://docs.gitea.com/api/1.20/
+type giteaForge struct {
+	baseURL string
+	token   string
+}
+
+// NewGiteaForge returns a Forge that talks to a Gitea or Forgejo instance at
+// baseURL, authenticated with an access token.
+func NewGiteaForge(baseURL, token string) (Forge, error) {
+	// Fail fast on a bad baseURL/token rather than only on the first call.
+	if _, err := gitea.NewClient(baseURL, gitea.SetToken(token)); err != nil {
+		return nil, err
+	}
+	return &giteaForge{baseURL: baseURL, token: token}, nil
+}
+
+// client builds a *gitea.Client scoped to ctx. The gitea SDK has no
+// per-call context parameter; a context is instead set on the client via
+// SetContext and picked up by whatever request that client issues next.
+// A giteaForge is shared across concurrent callers, so rather than hold a
+// forge-wide lock across each network round trip (which would serialize
+// all Gitea traffic, including unrelated apps' deploys, behind whichever
+// call happens to be in flight), each call builds its own short-lived
+// client instead.
+func (f *giteaForge) client(ctx context.Context) (*gitea.Client, error) {
+	client, err := gitea.NewClient(f.baseURL, gitea.SetToken(f.token))
+	if err != nil {
+		return nil, err
+	}
+	client.SetContext(ctx)
+	return client, nil
+}
+
+func (f *giteaForge) GetRef(ctx context.Context, owner, repo, name string) (*Ref, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ref, _, err := client.GetRepoRef(owner, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Ref{Name: ref.Ref, SHA: ref.Object.SHA}, nil
+}
+
+func (f *giteaForge) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	commit, _, err := client.GetSingleCommit(owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	return fromGiteaCommit(commit), nil
+}
+
+func (f *giteaForge) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	giteaEntries := make([]gitea.CreateGitTreeEntry, len(entries))
+	for i, e := range entries {
+		giteaEntries[i] = gitea.CreateGitTreeEntry{
+			Path:    e.Path,
+			Mode:    e.Mode,
+			Type:    e.Type,
+			Content: e.Content,
+		}
+	}
+
+	tree, _, err := client.CreateGitTree(owner, repo, baseTree, gitea.CreateGitTreeOptions{
+		BaseTree: baseTree,
+		Entries:  giteaEntries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{SHA: tree.SHA}, nil
+}
+
+func (f *giteaForge) CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created, _, err := client.CreateCommit(owner, repo, gitea.CreateCommitOption{
+		Message: commit.Message,
+		Tree:    commit.TreeSHA,
+		Parents: commit.Parents,
+		Author: &gitea.Identity{
+			Name:  commit.Author.Name,
+			Email: commit.Author.Email,
+		},
+		Committer: &gitea.Identity{
+			Name:  commit.Committer.Name,
+			Email: commit.Committer.Email,
+		},
+		Signature: commit.Signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromGiteaCommit(created), nil
+}
+
+// CreateTag implements the TagCreator interface by creating a Gitea/Forgejo
+// annotated tag object and pointing a "refs/tags/<name>" ref at it.
+func (f *giteaForge) CreateTag(ctx context.Context, owner, repo string, tag *Tag) (*Tag, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	created, _, err := client.CreateAnnotatedTag(owner, repo, &gitea.CreateAnnotatedTagOption{
+		TagName: tag.Name,
+		Message: tag.Message,
+		Object:  tag.SHA,
+		Tagger: &gitea.Identity{
+			Name:  tag.Tagger.Name,
+			Email: tag.Tagger.Email,
+		},
+		Signature: tag.Signature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating tag object: %v", err)
+	}
+
+	ref := "refs/tags/" + tag.Name
+	if _, _, err := client.CreateTag(owner, repo, &gitea.CreateTagOption{
+		TagName: ref,
+		Target:  created.SHA,
+	}); err != nil {
+		return nil, fmt.Errorf("creating ref %q: %v", ref, err)
+	}
+
+	return &Tag{Name: tag.Name, SHA: created.SHA, Message: tag.Message}, nil
+}
+
+func (f *giteaForge) Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, _, err := client.CreateBranchMerge(owner, repo, gitea.MergeBranchOption{
+		Base: mr.Base,
+		Head: mr.Head,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromGiteaCommit(merged), nil
+}
+
+func (f *giteaForge) ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, _, err := client.ListRepoCommits(owner, repo, gitea.ListCommitOptions{
+		SHA:  opts.Ref,
+		Path: opts.Path,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Commit, len(commits))
+	for i, c := range commits {
+		out[i] = fromGiteaCommit(c)
+	}
+	return out, nil
+}
+
+func (f *giteaForge) GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error) {
+	client, err := f.client(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contents, _, err := client.GetContents(owner, repo, ref, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if contents.Type == "dir" {
+		dirContents, _, err := client.ListContents(owner, repo, ref, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var dir []*Content
+		for _, c := range dirContents {
+			dir = append(dir, &Content{Name: c.Name, Path: c.Path, Type: c.Type})
+		}
+		return nil, dir, nil
+	}
+
+	raw, _, err := client.GetFile(owner, repo, ref, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Content{Name: contents.Name, Path: contents.Path, Type: "file", Content: raw}, nil, nil
+}
+
+// SetStatus implements the StatusReporter interface by creating a
+// Gitea/Forgejo commit status.
+//
+// https://docs.gitea.com/api/1.20/#tag/repository/operation/repoCreateStatus
+func (f *giteaForge) SetStatus(ctx context.Context, owner, repo, sha string, status *Status) error {
+	client, err := f.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	statusContext := status.Context
+	if statusContext == "" {
+		statusContext = "empire/deploy"
+	}
+
+	_, _, err = client.CreateStatus(owner, repo, sha, gitea.CreateStatusOption{
+		State:       giteaStatusState(status.State),
+		Context:     statusContext,
+		Description: status.Description,
+		TargetURL:   status.TargetURL,
+	})
+	return err
+}
+
+func giteaStatusState(s StatusState) gitea.StatusState {
+	switch s {
+	case StatusSuccess:
+		return gitea.StatusSuccess
+	case StatusFailure:
+		return gitea.StatusFailure
+	default:
+		return gitea.StatusPending
+	}
+}
+
+func fromGiteaCommit(c *gitea.Commit) *Commit {
+	commit := &Commit{SHA: c.SHA}
+	if c.RepoCommit != nil {
+		commit.Message = c.RepoCommit.Message
+		if c.RepoCommit.Tree != nil {
+			commit.TreeSHA = c.RepoCommit.Tree.SHA
+		}
+		if c.RepoCommit.Committer != nil {
+			commit.Committer = Signature{
+				Name:  c.RepoCommit.Committer.Name,
+				Email: c.RepoCommit.Committer.Email,
+				Date:  c.RepoCommit.Committer.Date,
+			}
+		}
+	}
+	for _, p := range c.Parents {
+		commit.Parents = append(commit.Parents, p.SHA)
+	}
+	return commit
+}
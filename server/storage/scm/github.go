@@ -0,0 +1,244 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/github"
+)
+
+// githubForge is a Forge implementation backed by the GitHub v3 Git Data API.
+//
+// https://developer.github.com/v3/git/
+type githubForge struct {
+	client *github.Client
+}
+
+// NewGitHubForge returns a Forge that talks to GitHub, using a client
+// authenticated with the given http.Client.
+func NewGitHubForge(c *http.Client) Forge {
+	return &githubForge{client: github.NewClient(c)}
+}
+
+func (f *githubForge) GetRef(ctx context.Context, owner, repo, name string) (*Ref, error) {
+	ref, _, err := f.client.Git.GetRef(ctx, owner, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Ref{Name: *ref.Ref, SHA: *ref.Object.SHA}, nil
+}
+
+func (f *githubForge) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	commit, _, err := f.client.Git.GetCommit(ctx, owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHubCommit(commit), nil
+}
+
+func (f *githubForge) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	ghEntries := make([]github.TreeEntry, len(entries))
+	for i, e := range entries {
+		ghEntries[i] = github.TreeEntry{
+			Path:    github.String(e.Path),
+			Mode:    github.String(e.Mode),
+			Type:    github.String(e.Type),
+			Content: github.String(e.Content),
+		}
+	}
+
+	tree, _, err := f.client.Git.CreateTree(ctx, owner, repo, baseTree, ghEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{SHA: *tree.SHA}, nil
+}
+
+func (f *githubForge) CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error) {
+	parents := make([]github.Commit, len(commit.Parents))
+	for i, sha := range commit.Parents {
+		parents[i] = github.Commit{SHA: github.String(sha)}
+	}
+
+	ghCommit := &github.Commit{
+		Message:   github.String(commit.Message),
+		Tree:      &github.Tree{SHA: github.String(commit.TreeSHA)},
+		Parents:   parents,
+		Author:    githubCommitAuthor(commit.Author),
+		Committer: githubCommitAuthor(commit.Committer),
+	}
+
+	var opts *github.CreateCommitOptions
+	if commit.Signature != "" {
+		opts = &github.CreateCommitOptions{Signature: github.String(commit.Signature)}
+	}
+
+	created, _, err := f.client.Git.CreateCommit(ctx, owner, repo, ghCommit, opts)
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHubCommit(created), nil
+}
+
+// CreateTag implements the TagCreator interface by creating a GitHub tag
+// object and pointing a "refs/tags/<name>" ref at it.
+//
+// https://developer.github.com/v3/git/tags/
+func (f *githubForge) CreateTag(ctx context.Context, owner, repo string, tag *Tag) (*Tag, error) {
+	ghTag := &github.Tag{
+		Tag:     github.String(tag.Name),
+		Message: github.String(tag.Message),
+		Object: &github.GitObject{
+			Type: github.String("commit"),
+			SHA:  github.String(tag.SHA),
+		},
+		Tagger: &github.CommitAuthor{
+			Name:  github.String(tag.Tagger.Name),
+			Email: github.String(tag.Tagger.Email),
+			Date:  &tag.Tagger.Date,
+		},
+	}
+
+	var opts *github.CreateTagOptions
+	if tag.Signature != "" {
+		opts = &github.CreateTagOptions{Signature: github.String(tag.Signature)}
+	}
+
+	created, _, err := f.client.Git.CreateTag(ctx, owner, repo, ghTag, opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating tag object: %v", err)
+	}
+
+	ref := "refs/tags/" + tag.Name
+	if _, _, err := f.client.Git.CreateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.String(ref),
+		Object: &github.GitObject{SHA: created.SHA},
+	}); err != nil {
+		return nil, fmt.Errorf("creating ref %q: %v", ref, err)
+	}
+
+	return &Tag{Name: tag.Name, SHA: *created.SHA, Message: tag.Message}, nil
+}
+
+func (f *githubForge) Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error) {
+	merge, _, err := f.client.Repositories.Merge(ctx, owner, repo, &github.RepositoryMergeRequest{
+		Base:          github.String(mr.Base),
+		Head:          github.String(mr.Head),
+		CommitMessage: github.String(mr.Message),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHubRepositoryCommit(merge), nil
+}
+
+func (f *githubForge) ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error) {
+	commits, _, err := f.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{
+		SHA:  opts.Ref,
+		Path: opts.Path,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Commit, len(commits))
+	for i, c := range commits {
+		out[i] = fromGitHubRepositoryCommit(&c)
+	}
+	return out, nil
+}
+
+func (f *githubForge) GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error) {
+	fileContent, dirContent, _, err := f.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{
+		Ref: ref,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var file *Content
+	if fileContent != nil {
+		raw, err := fileContent.Decode()
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding %q: %v", path, err)
+		}
+		file = &Content{Name: *fileContent.Name, Path: *fileContent.Path, Type: "file", Content: raw}
+	}
+
+	var dir []*Content
+	for _, c := range dirContent {
+		dir = append(dir, &Content{Name: *c.Name, Path: *c.Path, Type: *c.Type})
+	}
+
+	return file, dir, nil
+}
+
+// SetStatus implements the StatusReporter interface by creating a GitHub
+// commit status.
+//
+// https://developer.github.com/v3/repos/statuses/
+func (f *githubForge) SetStatus(ctx context.Context, owner, repo, sha string, status *Status) error {
+	statusContext := status.Context
+	if statusContext == "" {
+		statusContext = "empire/deploy"
+	}
+
+	_, _, err := f.client.Repositories.CreateStatus(ctx, owner, repo, sha, &github.RepoStatus{
+		State:       github.String(string(status.State)),
+		Context:     github.String(statusContext),
+		Description: github.String(status.Description),
+		TargetURL:   github.String(status.TargetURL),
+	})
+	return err
+}
+
+// githubCommitAuthor converts a Signature into the github.CommitAuthor
+// form that the Git Data API expects for a commit's "author"/"committer",
+// mirroring what CreateTag already does for Tagger.
+func githubCommitAuthor(sig Signature) *github.CommitAuthor {
+	return &github.CommitAuthor{
+		Name:  github.String(sig.Name),
+		Email: github.String(sig.Email),
+		Date:  &sig.Date,
+	}
+}
+
+func fromGitHubCommit(c *github.Commit) *Commit {
+	commit := &Commit{
+		SHA:     *c.SHA,
+		Message: *c.Message,
+	}
+	if c.Tree != nil && c.Tree.SHA != nil {
+		commit.TreeSHA = *c.Tree.SHA
+	}
+	for _, p := range c.Parents {
+		commit.Parents = append(commit.Parents, *p.SHA)
+	}
+	if c.Committer != nil {
+		commit.Committer = Signature{
+			Name:  c.Committer.GetName(),
+			Email: c.Committer.GetEmail(),
+			Date:  c.Committer.GetDate(),
+		}
+	}
+	return commit
+}
+
+func fromGitHubRepositoryCommit(c *github.RepositoryCommit) *Commit {
+	commit := &Commit{SHA: *c.SHA}
+	if c.Commit != nil {
+		commit.Message = c.Commit.GetMessage()
+		if c.Commit.Tree != nil {
+			commit.TreeSHA = c.Commit.Tree.GetSHA()
+		}
+		if c.Commit.Committer != nil {
+			commit.Committer = Signature{
+				Name:  c.Commit.Committer.GetName(),
+				Email: c.Commit.Committer.GetEmail(),
+				Date:  c.Commit.Committer.GetDate(),
+			}
+		}
+	}
+	return commit
+}
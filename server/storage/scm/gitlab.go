@@ -0,0 +1,319 @@
+package scm
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// gitlabForge is a Forge implementation backed by the GitLab Repository
+// Files and Commits APIs.
+//
+// https://docs.gitlab.com/ee/api/repository_files.html
+// https://docs.gitlab.com/ee/api/commits.html
+type gitlabForge struct {
+	client *gitlab.Client
+
+	// GitLab has no separate "create tree" endpoint; a commit is created
+	// directly from a branch and a list of file actions. We stash the
+	// entries from CreateTree here, keyed by a synthetic tree SHA, so
+	// that CreateCommit can turn them into commit actions.
+	mu    sync.Mutex
+	trees map[string][]TreeEntry
+
+	// GitLab's CreateCommit and CreateMergeRequest endpoints both take a
+	// branch name, not a SHA, so CreateCommit creates a scratch branch to
+	// stand in for the anonymous commit Storage wants and records it
+	// here, keyed by the SHA of the commit it produced, so that Merge can
+	// find it again from mr.Head.
+	branches map[string]string
+}
+
+// NewGitLabForge returns a Forge that talks to a GitLab instance (gitlab.com
+// or self-hosted) at baseURL, authenticated with a personal or project
+// access token.
+func NewGitLabForge(baseURL, token string) (Forge, error) {
+	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("creating gitlab client: %v", err)
+	}
+	return &gitlabForge{
+		client:   client,
+		trees:    make(map[string][]TreeEntry),
+		branches: make(map[string]string),
+	}, nil
+}
+
+func (f *gitlabForge) GetRef(ctx context.Context, owner, repo, name string) (*Ref, error) {
+	pid := projectID(owner, repo)
+	branch, _, err := f.client.Branches.GetBranch(pid, refName(name), gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &Ref{Name: name, SHA: branch.Commit.ID}, nil
+}
+
+func (f *gitlabForge) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	pid := projectID(owner, repo)
+	commit, _, err := f.client.Commits.GetCommit(pid, sha, nil, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fromGitLabCommit(commit), nil
+}
+
+// CreateTree has no direct GitLab equivalent; a GitLab commit is created by
+// sending a branch plus a list of file actions in one call. We remember the
+// entries under a synthetic SHA so that CreateCommit can use them.
+func (f *gitlabForge) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	sha := treeSHA(baseTree, entries)
+
+	f.mu.Lock()
+	f.trees[sha] = entries
+	f.mu.Unlock()
+
+	return &Tree{SHA: sha}, nil
+}
+
+func (f *gitlabForge) CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error) {
+	if commit.Signature != "" {
+		// GitLab's Commits API has no field for attaching a
+		// pre-computed signature; it only ever considers a commit
+		// "verified" if it can validate it against a GPG/SSH key
+		// registered on the committer's GitLab account. Silently
+		// dropping the signature here would produce an unsigned
+		// commit while the caller believes it configured signing.
+		return nil, fmt.Errorf("gitlab: forge does not support signing commits")
+	}
+
+	if len(commit.Parents) == 0 {
+		return nil, fmt.Errorf("gitlab: commit has no parent to branch from")
+	}
+
+	pid := projectID(owner, repo)
+
+	f.mu.Lock()
+	entries := f.trees[commit.TreeSHA]
+	delete(f.trees, commit.TreeSHA)
+	f.mu.Unlock()
+
+	actions := make([]*gitlab.CommitActionOptions, len(entries))
+	for i, e := range entries {
+		actions[i] = &gitlab.CommitActionOptions{
+			Action:   gitlab.Ptr(gitlab.FileUpdate),
+			FilePath: gitlab.Ptr(e.Path),
+			Content:  gitlab.Ptr(e.Content),
+		}
+	}
+
+	// commit.Parents[0] is a SHA, but CreateCommit's Branch field must
+	// name an existing branch. Create a scratch branch at that SHA to
+	// stand in for it; Merge deletes it again once it's been consumed.
+	branch, err := f.createScratchBranch(ctx, pid, commit.TreeSHA, commit.Parents[0])
+	if err != nil {
+		return nil, fmt.Errorf("creating scratch branch: %v", err)
+	}
+
+	created, _, err := f.client.Commits.CreateCommit(pid, &gitlab.CreateCommitOptions{
+		Branch:        gitlab.Ptr(branch),
+		CommitMessage: gitlab.Ptr(commit.Message),
+		Actions:       actions,
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		f.deleteBranch(ctx, pid, branch)
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.branches[created.ID] = branch
+	f.mu.Unlock()
+
+	return fromGitLabCommit(created), nil
+}
+
+// createScratchBranch creates a throwaway branch at sha, named after
+// treeSHA so that two concurrent releases (which always touch a different
+// tree) never collide. It's the branch CreateCommit commits onto and Merge
+// later opens a merge request from.
+func (f *gitlabForge) createScratchBranch(ctx context.Context, pid, treeSHA, sha string) (string, error) {
+	name := "empire/" + treeSHA
+	if _, _, err := f.client.Branches.CreateBranch(pid, &gitlab.CreateBranchOptions{
+		Branch: gitlab.Ptr(name),
+		Ref:    gitlab.Ptr(sha),
+	}, gitlab.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// deleteBranch removes a scratch branch created by createScratchBranch.
+// Errors are swallowed: the branch is disposable scratch state, not
+// something callers can act on.
+func (f *gitlabForge) deleteBranch(ctx context.Context, pid, name string) {
+	f.client.Branches.DeleteBranch(pid, name, gitlab.WithContext(ctx))
+}
+
+// Merge merges Head into Base by creating a merge request and immediately
+// accepting it, returning the resulting merge commit.
+func (f *gitlabForge) Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error) {
+	pid := projectID(owner, repo)
+
+	f.mu.Lock()
+	branch, ok := f.branches[mr.Head]
+	delete(f.branches, mr.Head)
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("gitlab: no scratch branch found for commit %q", mr.Head)
+	}
+	defer f.deleteBranch(ctx, pid, branch)
+
+	created, _, err := f.client.MergeRequests.CreateMergeRequest(pid, &gitlab.CreateMergeRequestOptions{
+		SourceBranch: gitlab.Ptr(branch),
+		TargetBranch: gitlab.Ptr(mr.Base),
+		Title:        gitlab.Ptr(mr.Message),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, _, err := f.client.MergeRequests.AcceptMergeRequest(pid, created.IID, &gitlab.AcceptMergeRequestOptions{
+		MergeCommitMessage: gitlab.Ptr(mr.Message),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Commit{SHA: accepted.MergeCommitSHA}, nil
+}
+
+func (f *gitlabForge) ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error) {
+	pid := projectID(owner, repo)
+
+	commits, _, err := f.client.Commits.ListCommits(pid, &gitlab.ListCommitsOptions{
+		RefName: gitlab.Ptr(opts.Ref),
+		Path:    gitlab.Ptr(opts.Path),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Commit, len(commits))
+	for i, c := range commits {
+		out[i] = fromGitLabCommit(c)
+	}
+	return out, nil
+}
+
+func (f *gitlabForge) GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error) {
+	pid := projectID(owner, repo)
+
+	tree, _, err := f.client.Repositories.ListTree(pid, &gitlab.ListTreeOptions{
+		Path: gitlab.Ptr(path),
+		Ref:  gitlab.Ptr(ref),
+	}, gitlab.WithContext(ctx))
+	if err == nil && len(tree) > 0 {
+		var dir []*Content
+		for _, t := range tree {
+			dir = append(dir, &Content{Name: t.Name, Path: t.Path, Type: gitlabEntryType(t.Type)})
+		}
+		return nil, dir, nil
+	}
+
+	file, _, err := f.client.RepositoryFiles.GetRawFile(pid, path, &gitlab.GetRawFileOptions{Ref: gitlab.Ptr(ref)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Content{Path: path, Type: "file", Content: file}, nil, nil
+}
+
+// SetStatus implements the StatusReporter interface by creating a GitLab
+// commit status.
+//
+// https://docs.gitlab.com/ee/api/commits.html#set-the-pipeline-status-of-a-commit
+func (f *gitlabForge) SetStatus(ctx context.Context, owner, repo, sha string, status *Status) error {
+	pid := projectID(owner, repo)
+
+	statusContext := status.Context
+	if statusContext == "" {
+		statusContext = "empire/deploy"
+	}
+
+	_, _, err := f.client.Commits.SetCommitStatus(pid, sha, &gitlab.SetCommitStatusOptions{
+		State:       gitlab.BuildStateValue(gitlabStatusState(status.State)),
+		Context:     gitlab.Ptr(statusContext),
+		Description: gitlab.Ptr(status.Description),
+		TargetURL:   gitlab.Ptr(status.TargetURL),
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+func gitlabStatusState(s StatusState) gitlab.BuildStateValue {
+	switch s {
+	case StatusSuccess:
+		return gitlab.Success
+	case StatusFailure:
+		return gitlab.Failed
+	default:
+		return gitlab.Pending
+	}
+}
+
+func projectID(owner, repo string) string {
+	return owner + "/" + repo
+}
+
+// refName strips the "heads/" or "refs/heads/" prefix that GitHub-style ref
+// names use, since GitLab's API addresses branches by name alone.
+func refName(name string) string {
+	switch {
+	case len(name) > len("refs/heads/") && name[:len("refs/heads/")] == "refs/heads/":
+		return name[len("refs/heads/"):]
+	case len(name) > len("heads/") && name[:len("heads/")] == "heads/":
+		return name[len("heads/"):]
+	default:
+		return name
+	}
+}
+
+func gitlabEntryType(t string) string {
+	if t == "tree" {
+		return "dir"
+	}
+	return "file"
+}
+
+// treeSHA derives a stable, content-addressed identifier for a set of tree
+// entries, mirroring the role a real tree SHA plays for forges that have an
+// actual tree object.
+func treeSHA(baseTree string, entries []TreeEntry) string {
+	h := sha1.New()
+	h.Write([]byte(baseTree))
+	for _, e := range entries {
+		h.Write([]byte(e.Path))
+		h.Write([]byte(e.Content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fromGitLabCommit(c *gitlab.Commit) *Commit {
+	commit := &Commit{
+		SHA:     c.ID,
+		Message: c.Message,
+	}
+	if len(c.ParentIDs) > 0 {
+		commit.Parents = c.ParentIDs
+	}
+	if c.CommitterName != "" {
+		commit.Committer = Signature{
+			Name:  c.CommitterName,
+			Email: c.CommitterEmail,
+			Date:  *c.CommittedDate,
+		}
+	}
+	return commit
+}
@@ -0,0 +1,97 @@
+package scm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signer produces a detached, ASCII-armored signature over a canonical Git
+// object (commit or tag), suitable for the "signature" field of a forge's
+// create-commit/create-tag API.
+type Signer interface {
+	Sign(object []byte) (signature string, err error)
+}
+
+// GPGSigner is a Signer backed by an OpenPGP entity, for GPG-signed commits
+// and tags.
+type GPGSigner struct {
+	Entity *openpgp.Entity
+}
+
+// NewGPGSigner returns a Signer that signs with entity's private key.
+func NewGPGSigner(entity *openpgp.Entity) *GPGSigner {
+	return &GPGSigner{Entity: entity}
+}
+
+func (s *GPGSigner) Sign(object []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, s.Entity, bytes.NewReader(object), nil); err != nil {
+		return "", fmt.Errorf("gpg signing: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// SSHSigner is a Signer backed by an SSH key, for SSH-signed commits and
+// tags, using the same SSHSIG armor that `git commit -S` produces when
+// gpg.format is set to "ssh".
+type SSHSigner struct {
+	Signer ssh.Signer
+
+	// Namespace is the signature namespace, which git sets to "git" for
+	// commit and tag signatures.
+	Namespace string
+}
+
+// NewSSHSigner returns a Signer that signs with signer's private key.
+func NewSSHSigner(signer ssh.Signer) *SSHSigner {
+	return &SSHSigner{Signer: signer, Namespace: "git"}
+}
+
+func (s *SSHSigner) Sign(object []byte) (string, error) {
+	sig, err := s.Signer.Sign(rand.Reader, signedData(s.Namespace, object))
+	if err != nil {
+		return "", fmt.Errorf("ssh signing: %v", err)
+	}
+	return armorSSHSignature(s.Signer.PublicKey(), s.Namespace, sig), nil
+}
+
+// canonicalCommit builds the exact byte sequence that a Git commit object's
+// signature is computed over: everything that goes into the object, minus
+// the "commit <size>\0" header used only when hashing.
+func canonicalCommit(c *Commit) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "tree %s\n", c.TreeSHA)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&buf, "author %s\n", formatSignature(c.Author))
+	fmt.Fprintf(&buf, "committer %s\n", formatSignature(c.Committer))
+	buf.WriteString("\n")
+	buf.WriteString(c.Message)
+
+	return buf.Bytes()
+}
+
+// canonicalTag builds the exact byte sequence that an annotated Git tag
+// object's signature is computed over.
+func canonicalTag(t *Tag, objectType string) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "object %s\n", t.SHA)
+	fmt.Fprintf(&buf, "type %s\n", objectType)
+	fmt.Fprintf(&buf, "tag %s\n", t.Name)
+	fmt.Fprintf(&buf, "tagger %s\n", formatSignature(t.Tagger))
+	buf.WriteString("\n")
+	buf.WriteString(t.Message)
+
+	return buf.Bytes()
+}
+
+func formatSignature(sig Signature) string {
+	return fmt.Sprintf("%s <%s> %d %s", sig.Name, sig.Email, sig.Date.Unix(), sig.Date.Format("-0700"))
+}
@@ -0,0 +1,110 @@
+package scm
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+func testCommit() *Commit {
+	return &Commit{
+		TreeSHA: "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		Parents: []string{"a5c19667710c4d8012a4a02345a92dabd24d3221"},
+		Message: "Deploy v42",
+		Author: Signature{
+			Name:  "Empire",
+			Email: "empire@localhost",
+			Date:  time.Unix(1700000000, 0),
+		},
+		Committer: Signature{
+			Name:  "Empire",
+			Email: "empire@localhost",
+			Date:  time.Unix(1700000000, 0),
+		},
+	}
+}
+
+// TestGPGSigner_RoundTrip signs a canonical commit with an in-memory OpenPGP
+// entity and checks that the armored detached signature verifies against the
+// same entity's public key, the way a forge or `git verify-commit` would.
+func TestGPGSigner_RoundTrip(t *testing.T) {
+	entity, err := openpgp.NewEntity("Empire", "", "empire@localhost", nil)
+	if err != nil {
+		t.Fatalf("generating pgp entity: %v", err)
+	}
+
+	signer := NewGPGSigner(entity)
+	object := canonicalCommit(testCommit())
+
+	sig, err := signer.Sign(object)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	keyring := openpgp.EntityList{entity}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(object), bytes.NewReader([]byte(sig))); err != nil {
+		t.Fatalf("signature did not verify: %v", err)
+	}
+}
+
+// TestSSHSigner_RoundTrip signs a canonical commit with an in-memory SSH key
+// and checks that the SSHSIG armor verifies with `ssh-keygen -Y verify`, the
+// same tool `git verify-commit` shells out to for gpg.format=ssh signatures.
+func TestSSHSigner_RoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	sshSigner, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("ssh.NewSignerFromKey: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("ssh.NewPublicKey: %v", err)
+	}
+
+	signer := NewSSHSigner(sshSigner)
+	object := canonicalCommit(testCommit())
+
+	sig, err := signer.Sign(object)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	dir := t.TempDir()
+
+	allowedSigners := filepath.Join(dir, "allowed_signers")
+	if err := os.WriteFile(allowedSigners, []byte("empire@localhost "+string(ssh.MarshalAuthorizedKey(sshPub))), 0o600); err != nil {
+		t.Fatalf("writing allowed_signers: %v", err)
+	}
+
+	sigFile := filepath.Join(dir, "commit.sig")
+	if err := os.WriteFile(sigFile, []byte(sig), 0o600); err != nil {
+		t.Fatalf("writing signature: %v", err)
+	}
+
+	cmd := exec.Command("ssh-keygen", "-Y", "verify",
+		"-f", allowedSigners,
+		"-I", "empire@localhost",
+		"-n", signer.Namespace,
+		"-s", sigFile,
+	)
+	cmd.Stdin = bytes.NewReader(object)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("ssh-keygen -Y verify: %v\n%s", err, out)
+	}
+}
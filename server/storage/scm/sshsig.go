@@ -0,0 +1,91 @@
+package scm
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshSigMagic is the preamble of the SSHSIG wire format used by OpenSSH's
+// `ssh-keygen -Y sign`, which is what `git commit -S` produces when
+// gpg.format is "ssh".
+//
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+const sshSigMagic = "SSHSIG"
+
+// armorSSHSignature wraps an SSH signature in the PEM-like armor that forges
+// and `git verify-commit`/`git verify-tag` expect.
+func armorSSHSignature(pub ssh.PublicKey, namespace string, sig *ssh.Signature) string {
+	blob := sshSigBlob(pub, namespace, sig)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	var buf bytes.Buffer
+	buf.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 0 {
+		n := 70
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		buf.WriteString(encoded[:n])
+		buf.WriteString("\n")
+		encoded = encoded[n:]
+	}
+	buf.WriteString("-----END SSH SIGNATURE-----\n")
+
+	return buf.String()
+}
+
+// signedData builds the blob that the SSHSIG spec requires a signature to
+// actually be computed over:
+//
+//	MAGIC_PREAMBLE || namespace || reserved || hash_algorithm || H(message)
+//
+// Note that this is not the same blob armorSSHSignature embeds in the
+// output armor (sshSigBlob): that one additionally carries the public key
+// and the resulting signature itself, and is never what gets signed.
+//
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func signedData(namespace string, message []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(sshSigMagic)
+
+	writeSSHString(&buf, []byte(namespace))
+	writeSSHString(&buf, nil) // reserved
+	writeSSHString(&buf, []byte("sha512"))
+	hash := sha512.Sum512(message)
+	writeSSHString(&buf, hash[:])
+
+	return buf.Bytes()
+}
+
+// sshSigBlob builds the binary SSHSIG blob: the raw magic preamble, a
+// uint32 version, then public key, namespace, reserved field, hash
+// algorithm and signature, each length-prefixed per the SSH wire format.
+func sshSigBlob(pub ssh.PublicKey, namespace string, sig *ssh.Signature) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(sshSigMagic)
+
+	writeUint32(&buf, 1) // version
+	writeSSHString(&buf, pub.Marshal())
+	writeSSHString(&buf, []byte(namespace))
+	writeSSHString(&buf, nil) // reserved
+	writeSSHString(&buf, []byte("sha512"))
+	writeSSHString(&buf, ssh.Marshal(sig))
+
+	return buf.Bytes()
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeSSHString(buf *bytes.Buffer, s []byte) {
+	writeUint32(buf, uint32(len(s)))
+	buf.Write(s)
+}
@@ -0,0 +1,52 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/remind101/empire"
+)
+
+// DefaultReleaseURL returns a Storage.ReleaseURL that points at Empire's
+// built-in release view, so that reported statuses link CI systems and PR
+// reviewers back to `/apps/{app}/releases/{version}` on baseURL.
+func DefaultReleaseURL(baseURL string) func(app *empire.App, version int) string {
+	return func(app *empire.App, version int) string {
+		return fmt.Sprintf("%s/apps/%s/releases/%d", baseURL, app.Name, version)
+	}
+}
+
+// StatusState is the state of a commit status.
+type StatusState string
+
+const (
+	StatusPending StatusState = "pending"
+	StatusSuccess StatusState = "success"
+	StatusFailure StatusState = "failure"
+)
+
+// Status describes a commit status (or check-run) to report against a SHA.
+type Status struct {
+	State StatusState
+
+	// Context namespaces this status among others reported against the
+	// same SHA (e.g. by CI). Defaults to "empire/deploy".
+	Context string
+
+	// Description is a short human readable summary, e.g. "deploy v42 to
+	// app foo".
+	Description string
+
+	// TargetURL, if set, points back at the Empire release view for this
+	// rollout.
+	TargetURL string
+}
+
+// StatusReporter posts a commit status against a SHA, so that CI systems and
+// PR reviewers can see the progress of an Empire release rollout inline on
+// the commit. Forge implementations that support it (GitHub, GitLab,
+// Gitea/Forgejo) implement this interface directly; Storage.Status can be
+// set to the Forge in use if it implements StatusReporter.
+type StatusReporter interface {
+	SetStatus(ctx context.Context, owner, repo, sha string, status *Status) error
+}
@@ -0,0 +1,511 @@
+package scm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/remind101/empire"
+	"github.com/remind101/empire/pkg/dotenv"
+	"github.com/remind101/empire/pkg/image"
+)
+
+// When interacting with a forge, we expect "/" to be the directory
+// separator.
+const DirectorySeparator = "/"
+
+// For blobs, the file mode should always be this value.
+const BlobMode = "100644"
+
+const (
+	FileVersion  = "VERSION"
+	FileEnv      = "app.env"
+	FileImage    = "image.txt"
+	FileServices = "services.json"
+)
+
+// Storage is an implementation of the empire.Storage interface that uses a
+// Forge (GitHub, GitLab, Gitea/Forgejo, Bitbucket Server, ...) to store
+// application configuration within a repository, as files.
+type Storage struct {
+	// The repository where configuration will be stored.
+	Owner, Repo string
+
+	// The base file path for where files will be committed.
+	BasePath string
+
+	// Ref to update after creating a commit.
+	Ref string
+
+	// Status, if set, is used to report the progress of a release
+	// rollout as a commit status on the commit that ReleasesCreate
+	// produces, transitioning from pending to success or failure.
+	Status StatusReporter
+
+	// StatusContext namespaces statuses reported via Status. Defaults to
+	// "empire/deploy".
+	StatusContext string
+
+	// ReleaseURL, if set, builds the target URL included in reported
+	// statuses, linking back at the Empire release view for app/version.
+	ReleaseURL func(app *empire.App, version int) string
+
+	// Signer, if set, is used to GPG/SSH-sign the commit that
+	// ReleasesCreate produces, and, if TagReleases is true, an annotated
+	// tag pointing at the resulting merge commit.
+	Signer Signer
+
+	// TagReleases, when true and Signer is configured, additionally
+	// creates a signed, annotated tag named "<app>-v<version>" on the
+	// merge commit produced by ReleasesCreate. Requires that the
+	// configured Forge implements TagCreator.
+	TagReleases bool
+
+	// CommitAuthor identifies the author/committer recorded on commits
+	// and tags created by this Storage. Defaults to "Empire
+	// <empire@localhost>".
+	CommitAuthor Signature
+
+	forge Forge
+}
+
+// NewStorage returns a new Storage instance backed by the given Forge.
+func NewStorage(forge Forge) *Storage {
+	return &Storage{forge: forge}
+}
+
+// ReleasesCreate creates a new release by making a commit to the
+// repository. In CLI terminology, it's roughly equivalent to the following:
+//
+//	> git checkout -b changes
+//	> touch app.json app.env image.txt services.json
+//	> git commit -m "Description of the changes"
+//	> git checkout base-ref
+//	> git merge --no-ff changes
+func (s *Storage) ReleasesCreate(ctx context.Context, app *empire.App, description string) (*empire.Release, error) {
+	// Auto increment the version number for this new release.
+	app.Version = app.Version + 1
+
+	// Get details about the ref we want to update.
+	ref, err := s.forge.GetRef(ctx, s.Owner, s.Repo, s.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("get %q ref: %v", s.Ref, err)
+	}
+
+	// Get the last commit on the ref we want to update. This will be used
+	// as the base for our changes.
+	lastCommit, err := s.forge.GetCommit(ctx, s.Owner, s.Repo, ref.SHA)
+	if err != nil {
+		return nil, fmt.Errorf("get last commit for %q: %v", ref.SHA, err)
+	}
+
+	// Generate our new tree entries with our app configuration.
+	treeEntries, err := s.treeEntries(app)
+	if err != nil {
+		return nil, fmt.Errorf("generating tree: %v", err)
+	}
+
+	// Create a new tree object, based on the last commit.
+	tree, err := s.forge.CreateTree(ctx, s.Owner, s.Repo, lastCommit.TreeSHA, treeEntries)
+	if err != nil {
+		return nil, fmt.Errorf("creating tree: %v", err)
+	}
+
+	author := s.commitAuthor()
+	newCommit := &Commit{
+		Message:   description,
+		TreeSHA:   tree.SHA,
+		Parents:   []string{lastCommit.SHA},
+		Author:    author,
+		Committer: author,
+	}
+
+	if s.Signer != nil {
+		sig, err := s.Signer.Sign(canonicalCommit(newCommit))
+		if err != nil {
+			return nil, fmt.Errorf("signing commit: %v", err)
+		}
+		newCommit.Signature = sig
+	}
+
+	// Create a new commit object with our new tree.
+	commit, err := s.forge.CreateCommit(ctx, s.Owner, s.Repo, newCommit)
+	if err != nil {
+		return nil, fmt.Errorf("creating commit: %v", err)
+	}
+
+	s.reportStatus(ctx, commit.SHA, StatusPending, app)
+
+	// Finally, we merge our commit into the existing ref. This will
+	// create a merge commit.
+	mergeCommit, err := s.forge.Merge(ctx, s.Owner, s.Repo, &MergeRequest{
+		Base: s.Ref,
+		Head: commit.SHA,
+	})
+	if err != nil {
+		s.reportStatus(ctx, commit.SHA, StatusFailure, app)
+		return nil, fmt.Errorf("merging %q into %q: %v", commit.SHA, s.Ref, err)
+	}
+
+	// commit.SHA is what we reported StatusPending against, so resolve it
+	// to success too, not just mergeCommit.SHA: CI systems and PR
+	// reviewers watching the pre-merge commit would otherwise see it
+	// stuck in "pending" forever.
+	s.reportStatus(ctx, commit.SHA, StatusSuccess, app)
+	s.reportStatus(ctx, mergeCommit.SHA, StatusSuccess, app)
+
+	if s.Signer != nil && s.TagReleases {
+		// The release has already landed and been reported as a
+		// success above, so tagging it is best effort, like status
+		// reporting itself: a transient signing error or a forge that
+		// doesn't implement TagCreator shouldn't turn an otherwise
+		// successful release into a hard error.
+		s.tagRelease(ctx, app, mergeCommit.SHA)
+	}
+
+	return &empire.Release{
+		App:         app,
+		Description: description,
+	}, nil
+}
+
+// tagRelease creates a signed, annotated tag named "<app>-v<version>" on
+// sha, using s.Signer. The configured Forge must implement TagCreator.
+func (s *Storage) tagRelease(ctx context.Context, app *empire.App, sha string) error {
+	tc, ok := s.forge.(TagCreator)
+	if !ok {
+		return fmt.Errorf("forge does not support creating tags")
+	}
+
+	tag := &Tag{
+		Name:    fmt.Sprintf("%s-v%d", app.Name, app.Version),
+		SHA:     sha,
+		Message: fmt.Sprintf("%s v%d", app.Name, app.Version),
+		Tagger:  s.commitAuthor(),
+	}
+
+	sig, err := s.Signer.Sign(canonicalTag(tag, "commit"))
+	if err != nil {
+		return fmt.Errorf("signing tag: %v", err)
+	}
+	tag.Signature = sig
+
+	_, err = tc.CreateTag(ctx, s.Owner, s.Repo, tag)
+	return err
+}
+
+func (s *Storage) commitAuthor() Signature {
+	author := s.CommitAuthor
+	if author.Name == "" {
+		author.Name = "Empire"
+	}
+	if author.Email == "" {
+		author.Email = "empire@localhost"
+	}
+	if author.Date.IsZero() {
+		author.Date = time.Now()
+	}
+	return author
+}
+
+// reportStatus posts a commit status for the release of app against sha, if
+// s.Status is configured. Errors are swallowed; status reporting is best
+// effort and should never fail a release.
+func (s *Storage) reportStatus(ctx context.Context, sha string, state StatusState, app *empire.App) {
+	if s.Status == nil {
+		return
+	}
+
+	statusContext := s.StatusContext
+	if statusContext == "" {
+		statusContext = "empire/deploy"
+	}
+
+	var targetURL string
+	if s.ReleaseURL != nil {
+		targetURL = s.ReleaseURL(app, app.Version)
+	}
+
+	s.Status.SetStatus(ctx, s.Owner, s.Repo, sha, &Status{
+		State:       state,
+		Context:     statusContext,
+		Description: fmt.Sprintf("deploy v%d to app %s", app.Version, app.Name),
+		TargetURL:   targetURL,
+	})
+}
+
+// Releases returns a list of the most recent releases for the give application.
+// It does so by looking what commits changed the app's VERSION file.
+func (s *Storage) Releases(ctx context.Context, q empire.ReleasesQuery) ([]*empire.Release, error) {
+	app := q.App
+
+	// Get a list of all commits that changed the VERSION file.
+	commits, err := s.forge.ListCommits(ctx, s.Owner, s.Repo, &ListCommitsOptions{
+		Ref:  s.Ref,
+		Path: s.Path(app.Name, FileVersion),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []*empire.Release
+
+	// TODO(ejholmes): This loop is pretty inneficient right now since it's
+	// N+1 and results in a lot of API calls to the forge.
+	for _, commit := range commits {
+		f := s.contentsAtRef(ctx, commit.SHA)
+		app, err := loadApp(f, &empire.App{Name: app.Name})
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, &empire.Release{
+			App:         app,
+			Description: commit.Message,
+			CreatedAt:   &commit.Committer.Date,
+		})
+	}
+
+	return releases, nil
+}
+
+// Apps returns a list of all apps matching q.
+func (s *Storage) Apps(ctx context.Context, q empire.AppsQuery) ([]*empire.App, error) {
+	_, directoryContent, err := s.GetContents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get contents of %q in %q: %v", s.BasePath, s.Ref, err)
+	}
+
+	var apps []*empire.App
+	for _, f := range directoryContent {
+		if f.Type == "dir" {
+			apps = append(apps, &empire.App{Name: f.Name})
+		}
+	}
+
+	return filterApps(apps, q), nil
+}
+
+func filterApps(apps []*empire.App, q empire.AppsQuery) []*empire.App {
+	if q.Name != nil {
+		apps = filter(apps, func(app *empire.App) bool {
+			return app.Name == *q.Name
+		})
+	}
+	return apps
+}
+
+func filter(apps []*empire.App, fn func(*empire.App) bool) []*empire.App {
+	var filtered []*empire.App
+	for _, app := range apps {
+		if fn(app) {
+			filtered = append(filtered, app)
+		}
+	}
+	return filtered
+}
+
+// AppsDestroy destroys the given app.
+func (s *Storage) AppsDestroy(app *empire.App) error {
+	return errors.New("AppsDestroy not implemented")
+}
+
+// AppsFind finds a single app that matches q, and loads it's configuration.
+func (s *Storage) AppsFind(ctx context.Context, q empire.AppsQuery) (*empire.App, error) {
+	apps, err := s.Apps(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) == 0 {
+		return nil, &empire.NotFoundError{Err: errors.New("app not found")}
+	}
+
+	app := apps[0]
+
+	return loadApp(s.contentsAtRef(ctx, s.Ref), app)
+}
+
+// GetContents gets some dir/file content in the repo, under the BasePath.
+func (s *Storage) GetContents(ctx context.Context, elem ...string) (*Content, []*Content, error) {
+	return s.contentsAtRef(ctx, s.Ref)(elem...)
+}
+
+// AppAt loads name's configuration as of ref, which may be a branch, tag,
+// or commit SHA, rather than s.Ref. It's used to compare an app's
+// configuration across commits, e.g. by a webhook handler reconciling a
+// push against the configuration that was already applied.
+func (s *Storage) AppAt(ctx context.Context, ref, name string) (*empire.App, error) {
+	return loadApp(s.contentsAtRef(ctx, ref), &empire.App{Name: name})
+}
+
+// contentsAtRef returns a contentFetcherFunc that fetches content at ref.
+func (s *Storage) contentsAtRef(ctx context.Context, ref string) contentFetcherFunc {
+	return contentFetcherFunc(func(elem ...string) (*Content, []*Content, error) {
+		fullPath := s.Path(elem...)
+		return s.forge.GetContents(ctx, s.Owner, s.Repo, fullPath, ref)
+	})
+}
+
+// ReleasesFind finds a release that matches q.
+func (s *Storage) ReleasesFind(ctx context.Context, q empire.ReleasesQuery) (*empire.Release, error) {
+	return nil, errors.New("ReleasesFind not implemented")
+}
+
+// Reset does nothing for the forge backed storage backend.
+func (s *Storage) Reset() error {
+	return errors.New("refusing to reset forge storage backend")
+}
+
+// IsHealthy always returns healthy for the forge backed storage backend.
+func (s *Storage) IsHealthy() error {
+	return nil
+}
+
+func (s *Storage) Path(elem ...string) string {
+	return PathJoin(s.BasePath, elem...)
+}
+
+// PathJoin joins the elem to basepath, in a way that disallows any path
+// traversals in forge APIs. This method:
+//
+// 1. Ensures that the returned path is _always_ under basepath.
+// 2. Ensures that any directory separates in individual path components in elem
+//    are stripped.
+//
+// Replacing this method with something like `filepath.Join` would result in
+// directory traversals.
+func PathJoin(basepath string, elem ...string) string {
+	var cleaned []string
+	for _, e := range elem {
+		cleaned = append(cleaned, url.QueryEscape(e))
+	}
+	return strings.Join(append([]string{basepath}, cleaned...), DirectorySeparator)
+}
+
+// treeEntries generates a list of TreeEntry describing the Empire App.
+func (s *Storage) treeEntries(app *empire.App) ([]TreeEntry, error) {
+	entries := []TreeEntry{
+		{
+			Path:    s.Path(app.Name, FileVersion),
+			Type:    "blob",
+			Mode:    BlobMode,
+			Content: fmt.Sprintf("v%d", app.Version),
+		},
+	}
+
+	if app.Environment != nil {
+		envFile := new(bytes.Buffer)
+		if err := dotenv.Write(envFile, app.Environment); err != nil {
+			return nil, err
+		}
+		entries = append(entries, TreeEntry{
+			Path:    s.Path(app.Name, FileEnv),
+			Type:    "blob",
+			Mode:    BlobMode,
+			Content: envFile.String(),
+		})
+	}
+
+	if app.Image != nil {
+		entries = append(entries, TreeEntry{
+			Path:    s.Path(app.Name, FileImage),
+			Type:    "blob",
+			Mode:    BlobMode,
+			Content: app.Image.String(),
+		})
+	}
+
+	if app.Formation != nil {
+		formation, err := jsonMarshal(app.Formation)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, TreeEntry{
+			Path:    s.Path(app.Name, FileServices),
+			Type:    "blob",
+			Mode:    BlobMode,
+			Content: string(formation),
+		})
+	}
+
+	return entries, nil
+}
+
+func jsonMarshal(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// contentFetcher is implemented by anything that can fetch file or directory
+// contents at a set of path elements relative to an app.
+type contentFetcher interface {
+	GetContents(...string) (*Content, []*Content, error)
+}
+
+type contentFetcherFunc func(...string) (*Content, []*Content, error)
+
+func (fn contentFetcherFunc) GetContents(elem ...string) (*Content, []*Content, error) {
+	return fn(elem...)
+}
+
+func loadApp(f contentFetcher, app *empire.App) (*empire.App, error) {
+	version, err := fileContent(f, PathJoin(app.Name, FileVersion))
+	if err != nil {
+		return nil, err
+	}
+	vi, err := strconv.Atoi(strings.TrimSpace(string(version))[1:])
+	if err != nil {
+		return nil, err
+	}
+	app.Version = vi
+
+	if err := decodeFile(f, PathJoin(app.Name, FileServices), &app.Formation); err != nil {
+		return nil, err
+	}
+
+	imageContent, err := fileContent(f, PathJoin(app.Name, FileImage))
+	if err != nil {
+		return nil, err
+	}
+	img, err := image.Decode(string(imageContent))
+	if err != nil {
+		return nil, err
+	}
+	app.Image = &img
+
+	envContent, err := fileContent(f, PathJoin(app.Name, FileEnv))
+	if err != nil {
+		return nil, err
+	}
+	env, err := dotenv.Read(bytes.NewReader(envContent))
+	if err != nil {
+		return nil, err
+	}
+	app.Environment = env
+
+	return app, nil
+}
+
+func decodeFile(f contentFetcher, path string, v interface{}) error {
+	raw, err := fileContent(f, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, &v)
+}
+
+func fileContent(f contentFetcher, path string) ([]byte, error) {
+	content, _, err := f.GetContents(path)
+	if err != nil {
+		return nil, fmt.Errorf("get contents of %q: %v", path, err)
+	}
+	if content == nil {
+		return nil, fmt.Errorf("get contents of %q: %v", path, ErrNotFound)
+	}
+
+	return content.Content, nil
+}
@@ -0,0 +1,144 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/remind101/empire"
+)
+
+// fakeForge is an in-memory Forge double used to exercise Storage's
+// orchestration of a release (status reporting, merging, tagging) without
+// depending on any one forge's wire format.
+type fakeForge struct {
+	mergeErr error
+	tagErr   error
+
+	statuses []fakeStatus
+}
+
+type fakeStatus struct {
+	sha   string
+	state StatusState
+}
+
+func (f *fakeForge) GetRef(ctx context.Context, owner, repo, name string) (*Ref, error) {
+	return &Ref{Name: name, SHA: "base-sha"}, nil
+}
+
+func (f *fakeForge) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	return &Commit{SHA: sha, TreeSHA: "base-tree"}, nil
+}
+
+func (f *fakeForge) CreateTree(ctx context.Context, owner, repo, baseTree string, entries []TreeEntry) (*Tree, error) {
+	return &Tree{SHA: "new-tree", Entries: entries}, nil
+}
+
+func (f *fakeForge) CreateCommit(ctx context.Context, owner, repo string, commit *Commit) (*Commit, error) {
+	commit.SHA = "new-commit"
+	return commit, nil
+}
+
+func (f *fakeForge) Merge(ctx context.Context, owner, repo string, mr *MergeRequest) (*Commit, error) {
+	if f.mergeErr != nil {
+		return nil, f.mergeErr
+	}
+	return &Commit{SHA: "merge-commit"}, nil
+}
+
+func (f *fakeForge) ListCommits(ctx context.Context, owner, repo string, opts *ListCommitsOptions) ([]*Commit, error) {
+	return nil, nil
+}
+
+func (f *fakeForge) GetContents(ctx context.Context, owner, repo, path, ref string) (*Content, []*Content, error) {
+	return nil, nil, nil
+}
+
+func (f *fakeForge) SetStatus(ctx context.Context, owner, repo, sha string, status *Status) error {
+	f.statuses = append(f.statuses, fakeStatus{sha: sha, state: status.State})
+	return nil
+}
+
+func (f *fakeForge) CreateTag(ctx context.Context, owner, repo string, tag *Tag) (*Tag, error) {
+	if f.tagErr != nil {
+		return nil, f.tagErr
+	}
+	return tag, nil
+}
+
+// fakeSigner always returns a fixed, valid-looking signature.
+type fakeSigner struct{}
+
+func (fakeSigner) Sign(object []byte) (string, error) {
+	return "signature", nil
+}
+
+func newTestStorage(forge *fakeForge) *Storage {
+	s := NewStorage(forge)
+	s.Owner, s.Repo, s.Ref = "acme", "config", "master"
+	s.Status = forge
+	return s
+}
+
+func TestStorage_ReleasesCreate_reportsStatusAgainstBothSHAs(t *testing.T) {
+	forge := &fakeForge{}
+	s := newTestStorage(forge)
+
+	if _, err := s.ReleasesCreate(context.Background(), &empire.App{Name: "myapp"}, "deploy"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fakeStatus{
+		{sha: "new-commit", state: StatusPending},
+		{sha: "new-commit", state: StatusSuccess},
+		{sha: "merge-commit", state: StatusSuccess},
+	}
+	if len(forge.statuses) != len(want) {
+		t.Fatalf("statuses = %+v, want %+v", forge.statuses, want)
+	}
+	for i, s := range want {
+		if forge.statuses[i] != s {
+			t.Errorf("statuses[%d] = %+v, want %+v", i, forge.statuses[i], s)
+		}
+	}
+}
+
+func TestStorage_ReleasesCreate_reportsFailureAgainstPreMergeSHA(t *testing.T) {
+	forge := &fakeForge{mergeErr: fmt.Errorf("merge conflict")}
+	s := newTestStorage(forge)
+
+	if _, err := s.ReleasesCreate(context.Background(), &empire.App{Name: "myapp"}, "deploy"); err == nil {
+		t.Fatal("ReleasesCreate: got nil error, want non-nil")
+	}
+
+	want := []fakeStatus{
+		{sha: "new-commit", state: StatusPending},
+		{sha: "new-commit", state: StatusFailure},
+	}
+	if len(forge.statuses) != len(want) {
+		t.Fatalf("statuses = %+v, want %+v", forge.statuses, want)
+	}
+	for i, s := range want {
+		if forge.statuses[i] != s {
+			t.Errorf("statuses[%d] = %+v, want %+v", i, forge.statuses[i], s)
+		}
+	}
+}
+
+func TestStorage_ReleasesCreate_tagFailureDoesNotFailTheRelease(t *testing.T) {
+	forge := &fakeForge{tagErr: fmt.Errorf("forge does not support creating tags")}
+	s := newTestStorage(forge)
+	s.Signer = fakeSigner{}
+	s.TagReleases = true
+
+	if _, err := s.ReleasesCreate(context.Background(), &empire.App{Name: "myapp"}, "deploy"); err != nil {
+		t.Fatalf("ReleasesCreate: got error %v, want nil (tag failures are best effort)", err)
+	}
+
+	// The release already succeeded before tagging was attempted.
+	last := forge.statuses[len(forge.statuses)-1]
+	if last.state != StatusSuccess {
+		t.Errorf("final reported status = %v, want %v", last.state, StatusSuccess)
+	}
+}